@@ -0,0 +1,91 @@
+// Package licensing bootstraps the unioffice/unipdf license so both the
+// docx->pdf sample and the mail-merge sample can share one code path instead
+// of duplicating the same switch statement.
+package licensing
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/unidoc/unioffice/common/license"
+	pdflicense "github.com/unidoc/unipdf/v3/common/license"
+)
+
+// Config carries the license options a sample's own config struct exposes
+// via conf flags/env vars. Exactly one of ApiKey, LicenseKey, LicenseFile or
+// LicenseDir is expected to be set.
+type Config struct {
+	ApiKey       string
+	LicenseKey   string
+	CustomerName string
+	LicenseFile  string
+	LicenseDir   string
+}
+
+// Apply registers whichever license source is configured with both the
+// unioffice and unipdf license packages. It returns an error instead of
+// exiting so callers keep control over how a failure is reported.
+func Apply(conf Config) error {
+	switch {
+	case conf.ApiKey != "":
+		if err := license.SetMeteredKey(conf.ApiKey); err != nil {
+			return fmt.Errorf("set unioffice api key: %w", err)
+		}
+		if err := pdflicense.SetMeteredKey(conf.ApiKey); err != nil {
+			return fmt.Errorf("set unipdf api key: %w", err)
+		}
+		return nil
+	case conf.LicenseFile != "":
+		key, err := os.ReadFile(conf.LicenseFile)
+		if err != nil {
+			return fmt.Errorf("read license file %s: %w", conf.LicenseFile, err)
+		}
+		return applyLicenseKey(string(key), conf.CustomerName)
+	case conf.LicenseDir != "":
+		key, path, err := readLicenseFromDir(conf.LicenseDir)
+		if err != nil {
+			return err
+		}
+		if err := applyLicenseKey(key, conf.CustomerName); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		return nil
+	case conf.LicenseKey != "":
+		return applyLicenseKey(conf.LicenseKey, conf.CustomerName)
+	default:
+		return fmt.Errorf("neither api key, license key, license file nor license dir provided")
+	}
+}
+
+func applyLicenseKey(key, customerName string) error {
+	if customerName == "" {
+		return fmt.Errorf("customer name required for license key")
+	}
+	if err := license.SetLicenseKey(key, customerName); err != nil {
+		return fmt.Errorf("set unioffice license key: %w", err)
+	}
+	if err := pdflicense.SetLicenseKey(key, customerName); err != nil {
+		return fmt.Errorf("set unipdf license key: %w", err)
+	}
+	return nil
+}
+
+// readLicenseFromDir picks the first `*.lic` file in dir (offline perpetual
+// codes are shipped as a single file per customer) and returns its contents.
+func readLicenseFromDir(dir string) (key string, path string, err error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.lic"))
+	if err != nil {
+		return "", "", fmt.Errorf("scan license dir %s: %w", dir, err)
+	}
+	if len(matches) == 0 {
+		return "", "", fmt.Errorf("no *.lic file found in %s", dir)
+	}
+
+	contents, err := os.ReadFile(matches[0])
+	if err != nil {
+		return "", "", fmt.Errorf("read license file %s: %w", matches[0], err)
+	}
+
+	return string(contents), matches[0], nil
+}
@@ -2,26 +2,118 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"image/png"
 	"log"
 	"os"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	cfg "github.com/ardanlabs/conf/v3"
 	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/aztec"
 	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/code39"
+	"github.com/boombuler/barcode/datamatrix"
+	"github.com/boombuler/barcode/ean"
+	"github.com/boombuler/barcode/pdf417"
 	"github.com/boombuler/barcode/qr"
+	"github.com/pflege-de/unidoc-image-issue/internal/licensing"
 	"github.com/unidoc/unioffice/common"
-	"github.com/unidoc/unioffice/common/license"
 	"github.com/unidoc/unioffice/document"
-	"github.com/unidoc/unioffice/document/convert"
 	"github.com/unidoc/unioffice/measurement"
-	pdflicense "github.com/unidoc/unipdf/v3/common/license"
 )
 
+// codeKind describes how to encode and size a single barcode/QR symbology.
+// Adding a new symbology only requires one entry in codeKinds. encode
+// receives the parsed placeholder options so symbologies that support extra
+// tuning (e.g. the QR error correction level) can honour them; kinds that
+// don't care about opts simply ignore the argument.
+type codeKind struct {
+	encode                          func(data string, opts placeholderOpts) (barcode.Barcode, error)
+	defaultWidthCm, defaultHeightCm float64
+	pixelW, pixelH                  int
+}
+
+// codeKinds maps the placeholder prefix (e.g. `barcode` in `{barcode:...}`)
+// to the symbology used to encode it.
+var codeKinds = map[string]codeKind{
+	"qrcode": {
+		encode: func(data string, opts placeholderOpts) (barcode.Barcode, error) {
+			ec := qr.M
+			if opts.hasEC {
+				ec = opts.ec
+			}
+			return qr.Encode(data, ec, qr.Auto)
+		},
+		defaultWidthCm:  qrCodeWidthHeight,
+		defaultHeightCm: qrCodeWidthHeight,
+		pixelW:          qrWidthDimension,
+		pixelH:          qrHeightDimension,
+	},
+	"barcode": {
+		encode: func(data string, opts placeholderOpts) (barcode.Barcode, error) {
+			return code128.Encode(data)
+		},
+		defaultWidthCm:  barcodeWidth,
+		defaultHeightCm: barcodeHeight,
+		pixelW:          barcodeWidthDimension,
+		pixelH:          barcodeHeightDimension,
+	},
+	"aztec": {
+		encode: func(data string, opts placeholderOpts) (barcode.Barcode, error) {
+			return aztec.Encode([]byte(data), 33, 0)
+		},
+		defaultWidthCm:  qrCodeWidthHeight,
+		defaultHeightCm: qrCodeWidthHeight,
+		pixelW:          qrWidthDimension,
+		pixelH:          qrHeightDimension,
+	},
+	"datamatrix": {
+		encode: func(data string, opts placeholderOpts) (barcode.Barcode, error) {
+			return datamatrix.Encode(data)
+		},
+		defaultWidthCm:  qrCodeWidthHeight,
+		defaultHeightCm: qrCodeWidthHeight,
+		pixelW:          qrWidthDimension,
+		pixelH:          qrHeightDimension,
+	},
+	"pdf417": {
+		encode: func(data string, opts placeholderOpts) (barcode.Barcode, error) {
+			return pdf417.Encode(data, 2)
+		},
+		defaultWidthCm:  barcodeWidth,
+		defaultHeightCm: barcodeHeight,
+		pixelW:          barcodeWidthDimension,
+		pixelH:          barcodeHeightDimension,
+	},
+	"ean13": {
+		encode: func(data string, opts placeholderOpts) (barcode.Barcode, error) {
+			code, err := ean.Encode(data)
+			if err != nil {
+				return nil, fmt.Errorf("ean13 requires a numeric EAN-13/EAN-8 payload: %w", err)
+			}
+			return code, nil
+		},
+		defaultWidthCm:  barcodeWidth,
+		defaultHeightCm: barcodeHeight,
+		pixelW:          barcodeWidthDimension,
+		pixelH:          barcodeHeightDimension,
+	},
+	"code39": {
+		encode: func(data string, opts placeholderOpts) (barcode.Barcode, error) {
+			return code39.Encode(data, false, true)
+		},
+		defaultWidthCm:  barcodeWidth,
+		defaultHeightCm: barcodeHeight,
+		pixelW:          barcodeWidthDimension,
+		pixelH:          barcodeHeightDimension,
+	},
+}
+
 const (
 	barcodeWidth      = 3.88
 	barcodeHeight     = 0.74
@@ -38,6 +130,10 @@ type config struct {
 	UniofficeLicenseKey   string `conf:"flag:license,env:LICENSE_KEY"`
 	UniofficeCustomerName string `conf:"flag:name,env:CUSTOMER_NAME"`
 	UniofficeApiKey       string `conf:"flag:key,env:API_KEY"`
+	UniofficeLicenseFile  string `conf:"flag:license-file,env:LICENSE_FILE"`
+	UniofficeLicenseDir   string `conf:"flag:license-dir,env:LICENSE_DIR"`
+	Serve                 string `conf:"flag:serve,env:SERVE_ADDR"`
+	MaxBodyBytes          int64  `conf:"flag:max-body-bytes,env:MAX_BODY_BYTES,default:10485760"`
 }
 
 func main() {
@@ -53,41 +149,25 @@ func main() {
 		os.Exit(1)
 	}
 
-	switch {
-	case conf.UniofficeApiKey != "":
-		if err := license.SetMeteredKey(conf.UniofficeApiKey); err != nil {
-			fmt.Println(err, "set unioffice api key")
-			os.Exit(1)
-		}
-		if err := pdflicense.SetMeteredKey(conf.UniofficeApiKey); err != nil {
-			fmt.Println(err, "set unipdf api key")
-			os.Exit(1)
-		}
-	case conf.UniofficeLicenseKey != "":
-		if conf.UniofficeCustomerName == "" {
-			fmt.Println("customer name required for license key")
-			os.Exit(1)
-		}
-		if err := license.SetLicenseKey(conf.UniofficeLicenseKey, conf.UniofficeCustomerName); err != nil {
-			fmt.Println(err, "set unioffice license key")
-			os.Exit(1)
-		}
-		if err := pdflicense.SetLicenseKey(conf.UniofficeLicenseKey, conf.UniofficeCustomerName); err != nil {
-			fmt.Println(err, "set unipdf license key")
-			os.Exit(1)
-		}
-	default:
-		fmt.Println("neither api or license key provided")
-		os.Exit(1)
+	if err := licensing.Apply(licensing.Config{
+		ApiKey:       conf.UniofficeApiKey,
+		LicenseKey:   conf.UniofficeLicenseKey,
+		CustomerName: conf.UniofficeCustomerName,
+		LicenseFile:  conf.UniofficeLicenseFile,
+		LicenseDir:   conf.UniofficeLicenseDir,
+	}); err != nil {
+		log.Fatal(err)
+	}
+
+	if conf.Serve != "" {
+		log.Fatal(serve(conf.Serve, conf.MaxBodyBytes))
 	}
 
-	doc, err := document.Open("document.docx")
+	templateBytes, err := os.ReadFile("document.docx")
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	defer doc.Close()
-
 	mappings := make(map[string]string)
 	f, err := os.Open("mappings.json")
 	if err != nil {
@@ -100,78 +180,58 @@ func main() {
 		log.Fatal(err)
 	}
 
-	log.Printf("%v\n", doc.MergeFields())
-	doc.MailMerge(mappings)
-
-	fields := doc.FormFields()
-
-	for _, field := range fields {
-		log.Printf("DocField %s[%s]: %v\n", field.Name(), field.Type().String(), field.PossibleValues())
-		if field.Type() == document.FormFieldTypeCheckBox {
-			// name can be set in word via right click on the checkbox, and setting a value in "bookmark"
-			// value is either "true" or "false" for checkboxes
-			val, ok := mappings[field.Name()]
-			isChecked := ok && strings.ToLower(val) == "true"
-			field.SetChecked(isChecked)
-		}
-	}
-
-	err = fillMappings(doc, mappings)
+	pdf, err := Render(context.Background(), templateBytes, mappings, OutputPDF)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	// doc has to be copied so the eventually added images of barcodes are also exported to the PDF
-	renewedDoc, err := doc.Copy()
-	if err != nil {
+	if err := os.WriteFile("issue536.pdf", pdf, 0o644); err != nil {
 		log.Fatal(err)
 	}
+}
 
-	temporaryDocxFile, err := os.CreateTemp(".", "*.docx")
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer os.Remove(temporaryDocxFile.Name())
-	defer temporaryDocxFile.Close()
+// paragraphSource is anything that holds paragraphs and tables, so the table
+// walk below can be written once and reused for the document body, headers,
+// footers and table cells (tables can themselves contain nested tables).
+type paragraphSource interface {
+	Paragraphs() []document.Paragraph
+	Tables() []document.Table
+}
 
-	err = renewedDoc.SaveToFile(temporaryDocxFile.Name())
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer renewedDoc.Close()
+// collectParagraphs returns every paragraph reachable from src: its direct
+// paragraphs, plus the paragraphs of every cell of every table (recursing
+// into nested tables).
+func collectParagraphs(src paragraphSource) []document.Paragraph {
+	paragraphs := append([]document.Paragraph(nil), src.Paragraphs()...)
 
-	completed, err := document.Open(temporaryDocxFile.Name())
-	if err != nil {
-		log.Fatal(err)
+	for _, table := range src.Tables() {
+		for _, row := range table.Rows() {
+			for _, cell := range row.Cells() {
+				paragraphs = append(paragraphs, collectParagraphs(cell)...)
+			}
+		}
 	}
-	defer completed.Close()
 
-	pdfDoc := convert.ConvertToPdf(completed)
-	err = pdfDoc.WriteToFile("issue536.pdf")
-	if err != nil {
-		log.Fatal(err)
-	}
+	return paragraphs
 }
 
 func fillMappings(doc *document.Document, mappings map[string]string) error {
-	doc.StructuredDocumentTags()
-	paragraphs := make([]document.Paragraph, len(doc.Paragraphs()))
-	for i, p := range doc.Paragraphs() {
-		paragraphs[i] = p
+	paragraphs := collectParagraphs(doc)
+
+	for _, header := range doc.Headers() {
+		paragraphs = append(paragraphs, collectParagraphs(header)...)
 	}
 
 	for _, footer := range doc.Footers() {
-		for _, paragraph := range footer.Paragraphs() {
-			err := replaceBarcodeAndQRCode(paragraph, doc, mappings)
-			if err != nil {
-				return err
-			}
-		}
+		paragraphs = append(paragraphs, collectParagraphs(footer)...)
+	}
+
+	for _, sdt := range doc.StructuredDocumentTags() {
+		paragraphs = append(paragraphs, collectParagraphs(sdt)...)
 	}
 
 	for _, paragraph := range paragraphs {
-		err := replaceBarcodeAndQRCode(paragraph, doc, mappings)
-		if err != nil {
+		if err := replaceBarcodeAndQRCode(paragraph, doc, mappings); err != nil {
 			return err
 		}
 	}
@@ -184,20 +244,23 @@ func replaceBarcodeAndQRCode(paragraph document.Paragraph, doc *document.Documen
 	const openTag = '{'
 	const closingTag = '}'
 
-	placeholders := extractPlaceholders(paragraph.Runs(), openTag, closingTag)
+	placeholders, err := extractPlaceholders(paragraph, openTag, closingTag)
+	if err != nil {
+		return err
+	}
 
 	// The first run is replaced with a barcode or qrcode.
 	// All other runs associated to the placeholder are deleted.
-	for name, runs := range placeholders {
-		replaceMe := runs[0]
-		deleteMe := runs[1:]
+	for _, p := range placeholders {
+		replaceMe := p.runs[0]
+		deleteMe := p.runs[1:]
 
-		val, ok := mappings[name]
+		val, ok := mappings[p.mappingKey]
 		if !ok || len(val) < 1 {
 			continue
 		}
 
-		err := handleRun(replaceMe, name, mappings, doc)
+		err := handleRun(replaceMe, p, mappings, doc)
 		if err != nil {
 			return err
 		}
@@ -209,34 +272,78 @@ func replaceBarcodeAndQRCode(paragraph document.Paragraph, doc *document.Documen
 	return nil
 }
 
-// extractTemplates takes a set of runs and extracts the content between openingTag and closingTag.
-// The returned map consists of all runs associated with this placeholder.
-func extractPlaceholders(runs []document.Run, openingTag, closingTag rune) map[string][]document.Run {
-	placeholders := make(map[string][]document.Run)
+// placeholder is a single `{...}` token found in a paragraph, resolved to the
+// symbology it selects, the runs it spans, and any inline sizing/encoding
+// options it carries.
+type placeholder struct {
+	kind       codeKind
+	mappingKey string
+	opts       placeholderOpts
+	runs       []document.Run
+}
 
-	var associatedRuns []document.Run
+// placeholderOpts holds the parsed `{kind:opts:key}` options block. Zero
+// values mean "use the codeKind default".
+type placeholderOpts struct {
+	width, height       measurement.Distance
+	hasWidth, hasHeight bool
+	preserveAspect      bool
+	ec                  qr.ErrorCorrectionLevel
+	hasEC               bool
+}
+
+// extractPlaceholders walks paragraph's runs and extracts the placeholders
+// delimited by openingTag and closingTag, resolving each one to its
+// symbology, mapping key and options. Runs that only partially belong to a
+// placeholder (literal text before the `{` or after the `}`) are split off
+// into their own runs, copying the original run's properties, so surrounding
+// text keeps its formatting once the placeholder run is replaced by an
+// image.
+func extractPlaceholders(paragraph document.Paragraph, openingTag, closingTag rune) ([]placeholder, error) {
+	var placeholders []placeholder
+
+	cutter := newRunCutter(paragraph)
+
+	var tokenRuns []document.Run
+	var tokenStartRun document.Run
+	var tokenStartOffset int
 	var constructed string
 	var opened bool
-	for _, r := range runs {
-		for _, c := range r.Text() {
+
+	for _, r := range paragraph.Runs() {
+		text := r.Text()
+		for idx, c := range text {
 			switch c {
 			case openingTag:
 				opened = true
-				constructed += string(c)
+				constructed = string(c)
+				tokenStartRun = r
+				tokenStartOffset = idx
+				tokenRuns = []document.Run{r}
 			case closingTag:
-				if opened {
-					constructed += string(c)
-					associatedRuns = append(associatedRuns, r)
-
-					// The placholder name without open & closing tags.
-					name := strings.ToLower(constructed[1 : len(constructed)-1])
-					placeholders[name] = associatedRuns
+				if !opened {
+					continue
+				}
+				constructed += string(c)
+				if len(tokenRuns) == 0 || tokenRuns[len(tokenRuns)-1] != r {
+					tokenRuns = append(tokenRuns, r)
+				}
 
-					// closing
-					opened = false
-					associatedRuns = []document.Run{}
-					constructed = ""
+				// The placeholder content without open & closing tags.
+				token := strings.ToLower(constructed[1 : len(constructed)-1])
+				p, ok, err := parsePlaceholderToken(token)
+				if err != nil {
+					return nil, fmt.Errorf("placeholder [%s]: %w", token, err)
 				}
+				if ok {
+					endOffset := idx + utf8.RuneLen(c)
+					p.runs = cutter.splitPlaceholderRuns(tokenRuns, tokenStartRun, tokenStartOffset, r, endOffset)
+					placeholders = append(placeholders, p)
+				}
+
+				opened = false
+				constructed = ""
+				tokenRuns = nil
 			default:
 				if opened {
 					constructed += string(c)
@@ -244,31 +351,269 @@ func extractPlaceholders(runs []document.Run, openingTag, closingTag rune) map[s
 			}
 		}
 
-		if opened {
-			associatedRuns = append(associatedRuns, r)
+		if opened && (len(tokenRuns) == 0 || tokenRuns[len(tokenRuns)-1] != r) {
+			tokenRuns = append(tokenRuns, r)
+		}
+	}
+
+	return placeholders, nil
+}
+
+// runCutter carves the literal text surrounding placeholders out of the runs
+// that contain them, so it survives the placeholder runs being cleared and
+// replaced by images later on. Offsets passed to splitPlaceholderRuns are
+// always relative to a run's ORIGINAL text (as scanned by extractPlaceholders),
+// never to whatever text the run currently holds. runCutter tracks, per run,
+// how much of it has already been carved off the front and which run now
+// holds the remainder, so a run containing several back-to-back placeholders
+// (e.g. "{qrcode:a}{qrcode:b}") is split correctly instead of the second
+// split slicing into text the first split already removed.
+type runCutter struct {
+	paragraph document.Paragraph
+	cursors   map[document.Run]*runCursor
+}
+
+// runCursor tracks, for one original run, the run that currently holds its
+// not-yet-carved tail and the original-text offset that tail starts at.
+type runCursor struct {
+	tail   document.Run
+	carved int
+}
+
+func newRunCutter(paragraph document.Paragraph) *runCutter {
+	return &runCutter{paragraph: paragraph, cursors: map[document.Run]*runCursor{}}
+}
+
+func (rc *runCutter) cursorFor(r document.Run) *runCursor {
+	c, ok := rc.cursors[r]
+	if !ok {
+		c = &runCursor{tail: r, carved: 0}
+		rc.cursors[r] = c
+	}
+	return c
+}
+
+// cutAt splits r's current tail so that the text up to the original offset x
+// becomes its own run (returned), and the text from x onward becomes the
+// run's new tail. Calling cutAt again on r with a larger x picks up from
+// where the previous cut left off, so repeated cuts carve the run left to
+// right without ever re-slicing already-carved text.
+func (rc *runCutter) cutAt(r document.Run, x int) document.Run {
+	c := rc.cursorFor(r)
+	if x == c.carved {
+		return c.tail
+	}
+
+	tailText := c.tail.Text()
+	relOffset := x - c.carved
+	frontText := tailText[:relOffset]
+	restText := tailText[relOffset:]
+
+	frontRun := c.tail
+	frontRun.Clear()
+	frontRun.AddText(frontText)
+
+	newTail := rc.paragraph.InsertRunAfter(frontRun)
+	copyRunProperties(newTail, frontRun)
+	newTail.AddText(restText)
+
+	c.tail = newTail
+	c.carved = x
+
+	return frontRun
+}
+
+// splitPlaceholderRuns carves the literal text surrounding a placeholder
+// (before startOffset in startRun, after endOffset in endRun) into their own
+// runs, copying the original run's properties. It returns the runs that make
+// up the placeholder itself, in document order. Placeholders must be passed
+// in left-to-right document order so a run's cursor always advances forward.
+func (rc *runCutter) splitPlaceholderRuns(tokenRuns []document.Run, startRun document.Run, startOffset int, endRun document.Run, endOffset int) []document.Run {
+	rc.cutAt(startRun, startOffset) // carve off any literal text before the placeholder
+
+	if startRun == endRun {
+		placeholderRun := rc.cutAt(startRun, endOffset)
+		return []document.Run{placeholderRun}
+	}
+
+	placeholderStartRun := rc.cursorFor(startRun).tail
+	placeholderEndRun := rc.cutAt(endRun, endOffset)
+
+	runs := make([]document.Run, 0, len(tokenRuns))
+	runs = append(runs, placeholderStartRun)
+	runs = append(runs, tokenRuns[1:len(tokenRuns)-1]...)
+	runs = append(runs, placeholderEndRun)
+	return runs
+}
+
+// copyRunProperties copies src's run properties (bold, italic, font, size,
+// color, ...) onto dst so text split off from src keeps its formatting.
+func copyRunProperties(dst, src document.Run) {
+	if src.X().RPr != nil {
+		dst.X().RPr = src.X().RPr
+	}
+}
+
+// parsePlaceholderToken parses the content of a `{...}` token into a
+// placeholder. The grammar is:
+//
+//	{kind}                    legacy form: kind is also the mapping key
+//	{kind:key}                explicit mapping key, default size/options
+//	{kind:w=5cm,h=1cm,ec=H:key}  explicit options and mapping key
+//
+// ok is false when the token doesn't start with a known symbology, which
+// means it isn't a barcode/QR placeholder at all (e.g. a MailMerge field).
+func parsePlaceholderToken(token string) (placeholder, bool, error) {
+	parts := strings.Split(token, ":")
+
+	kindName := trimSpaceAndToLower(parts[0])
+	kind, known := codeKinds[kindName]
+	if !known {
+		// Fall back to the legacy prefix match (e.g. `{barcode_invoice}`)
+		// for callers that never used the `:`-separated grammar.
+		kind, known = lookupCodeKind(token)
+		if !known {
+			return placeholder{}, false, nil
+		}
+		return placeholder{kind: kind, mappingKey: token}, true, nil
+	}
+
+	switch len(parts) {
+	case 1:
+		return placeholder{kind: kind, mappingKey: kindName}, true, nil
+	case 2:
+		return placeholder{kind: kind, mappingKey: strings.TrimSpace(parts[1])}, true, nil
+	default:
+		opts, err := parsePlaceholderOpts(parts[1])
+		if err != nil {
+			return placeholder{}, false, err
+		}
+		mappingKey := strings.TrimSpace(strings.Join(parts[2:], ":"))
+		return placeholder{kind: kind, mappingKey: mappingKey, opts: opts}, true, nil
+	}
+}
+
+// parsePlaceholderOpts parses a comma-separated `k=v` options block, e.g.
+// `w=5cm,h=1cm,ec=H`.
+func parsePlaceholderOpts(raw string) (placeholderOpts, error) {
+	var opts placeholderOpts
+
+	for _, kv := range strings.Split(raw, ",") {
+		kv = strings.TrimSpace(kv)
+		if kv == "" {
+			continue
+		}
+
+		k, v, found := strings.Cut(kv, "=")
+		if !found {
+			return opts, fmt.Errorf("malformed option %q, expected key=value", kv)
+		}
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+
+		switch k {
+		case "w":
+			d, err := parseDistance(v)
+			if err != nil {
+				return opts, fmt.Errorf("option w: %w", err)
+			}
+			opts.width, opts.hasWidth = d, true
+		case "h":
+			d, err := parseDistance(v)
+			if err != nil {
+				return opts, fmt.Errorf("option h: %w", err)
+			}
+			opts.height, opts.hasHeight = d, true
+		case "size":
+			d, err := parseDistance(v)
+			if err != nil {
+				return opts, fmt.Errorf("option size: %w", err)
+			}
+			opts.width, opts.hasWidth = d, true
+			opts.height, opts.hasHeight = d, true
+		case "ec":
+			ec, err := parseErrorCorrectionLevel(v)
+			if err != nil {
+				return opts, fmt.Errorf("option ec: %w", err)
+			}
+			opts.ec, opts.hasEC = ec, true
+		case "aspect":
+			switch v {
+			case "preserve":
+				opts.preserveAspect = true
+			case "fixed":
+				opts.preserveAspect = false
+			default:
+				return opts, fmt.Errorf("option aspect: unknown value %q, want preserve or fixed", v)
+			}
+		default:
+			return opts, fmt.Errorf("unknown option %q", k)
+		}
+	}
+
+	return opts, nil
+}
+
+// parseDistance parses a `w`/`h`/`size` value such as `5cm`, `1in` or
+// `300px` into a measurement.Distance.
+func parseDistance(v string) (measurement.Distance, error) {
+	units := []struct {
+		suffix string
+		factor measurement.Distance
+	}{
+		{"cm", measurement.Centimeter},
+		{"mm", measurement.Millimeter},
+		{"in", measurement.Inch},
+		{"px", 0}, // handled separately below, pixels aren't a measurement.Distance unit
+	}
+
+	for _, u := range units {
+		if !strings.HasSuffix(v, u.suffix) {
+			continue
+		}
+		num := strings.TrimSuffix(v, u.suffix)
+		f, err := strconv.ParseFloat(num, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid distance %q: %w", v, err)
 		}
+		if u.suffix == "px" {
+			// Assume a 96 DPI pixel grid, as is conventional for screen assets.
+			return measurement.Distance(f/96) * measurement.Inch, nil
+		}
+		return measurement.Distance(f) * u.factor, nil
 	}
 
-	return placeholders
+	return 0, fmt.Errorf("unsupported unit in %q, want cm, mm, in or px", v)
 }
 
-func handleRun(r document.Run, key string, mappings map[string]string, doc *document.Document) error {
-	// verify if the key is a valid barcode or qrcode placeholder.
-	if !(isBarcode(key) || isQRCode(key)) {
-		return fmt.Errorf("invalid placeholder detected: [%s]", key)
+// parseErrorCorrectionLevel maps the `ec=` option (L|M|Q|H) onto the
+// boombuler/barcode/qr error correction levels.
+func parseErrorCorrectionLevel(v string) (qr.ErrorCorrectionLevel, error) {
+	switch strings.ToUpper(v) {
+	case "L":
+		return qr.L, nil
+	case "M":
+		return qr.M, nil
+	case "Q":
+		return qr.Q, nil
+	case "H":
+		return qr.H, nil
+	default:
+		return 0, fmt.Errorf("unknown error correction level %q, want one of L, M, Q, H", v)
 	}
+}
 
-	replaceValue, ok := mappings[key]
+func handleRun(r document.Run, p placeholder, mappings map[string]string, doc *document.Document) error {
+	replaceValue, ok := mappings[p.mappingKey]
 	if !ok {
-		return fmt.Errorf("failed to replace [%s], seems like it's missing in the payload. Using key as fallback value", key)
+		return fmt.Errorf("failed to replace [%s], seems like it's missing in the payload", p.mappingKey)
 	}
 
-	codeImg, err := insertCode(key, replaceValue)
+	codeImg, err := insertCode(p.kind, p.opts, replaceValue)
 	if err != nil {
-		return err
+		return fmt.Errorf("encoding [%s]: %w", p.mappingKey, err)
 	}
 
-	err = addImageToDoc(doc, r, codeImg, key)
+	err = addImageToDoc(doc, r, codeImg, p.kind, p.opts)
 	if err != nil {
 		return err
 	}
@@ -276,21 +621,9 @@ func handleRun(r document.Run, key string, mappings map[string]string, doc *docu
 	return nil
 }
 
-func addImageToDoc(doc *document.Document, r document.Run, qrCodeImg barcode.Barcode, key string) error {
-	var width, height float64
-
-	if isQRCode(key) {
-		width = qrCodeWidthHeight
-		height = qrCodeWidthHeight
-	} else if isBarcode(key) {
-		width = barcodeWidth
-		height = barcodeHeight
-	} else {
-		return errors.New("unsupported code as input")
-	}
-
+func addImageToDoc(doc *document.Document, r document.Run, codeImg barcode.Barcode, kind codeKind, opts placeholderOpts) error {
 	buf := new(bytes.Buffer)
-	err := png.Encode(buf, qrCodeImg)
+	err := png.Encode(buf, codeImg)
 	if err != nil {
 		return err
 	}
@@ -305,7 +638,9 @@ func addImageToDoc(doc *document.Document, r document.Run, qrCodeImg barcode.Bar
 		return err
 	}
 
-	err = replaceWithImage(r, imgRef, measurement.Distance(width), measurement.Distance(height))
+	width, height := resolveSize(kind, opts)
+
+	err = replaceWithImage(r, imgRef, width, height)
 	if err != nil {
 		return err
 	}
@@ -313,6 +648,31 @@ func addImageToDoc(doc *document.Document, r document.Run, qrCodeImg barcode.Bar
 	return nil
 }
 
+// resolveSize applies the codeKind defaults, any explicit w/h/size options,
+// and (when only one of width/height was given and preserveAspect is set)
+// scales the missing dimension to keep the symbology's native aspect ratio.
+func resolveSize(kind codeKind, opts placeholderOpts) (width, height measurement.Distance) {
+	width = measurement.Distance(kind.defaultWidthCm) * measurement.Centimeter
+	height = measurement.Distance(kind.defaultHeightCm) * measurement.Centimeter
+
+	switch {
+	case opts.hasWidth && opts.hasHeight:
+		width, height = opts.width, opts.height
+	case opts.hasWidth:
+		width = opts.width
+		if opts.preserveAspect {
+			height = width * measurement.Distance(kind.pixelH) / measurement.Distance(kind.pixelW)
+		}
+	case opts.hasHeight:
+		height = opts.height
+		if opts.preserveAspect {
+			width = height * measurement.Distance(kind.pixelW) / measurement.Distance(kind.pixelH)
+		}
+	}
+
+	return width, height
+}
+
 func replaceWithImage(r document.Run, imgRef common.ImageRef, width, height measurement.Distance) error {
 	r.Clear()
 	inlineDrawing, err := r.AddDrawingInline(imgRef)
@@ -320,36 +680,19 @@ func replaceWithImage(r document.Run, imgRef common.ImageRef, width, height meas
 		return err
 	}
 
-	inlineDrawing.SetSize(width*measurement.Centimeter, height*measurement.Centimeter)
+	inlineDrawing.SetSize(width, height)
 	return nil
 }
 
-func insertCode(key string, replaceValue string) (barcode.Barcode, error) {
-	var width, height int
-	var code barcode.Barcode
-	var err error
-
-	if isQRCode(key) {
-		// generated qrcode from value has to be converted to an image to retrieve the bytes
-		// bytes are used to create image and the needed image reference by adding it to the document
-		code, err = qr.Encode(replaceValue, qr.M, qr.Auto)
-		if err != nil {
-			return nil, err
-		}
-		width = qrWidthDimension
-		height = qrHeightDimension
-	} else if isBarcode(key) {
-		code, err = code128.Encode(replaceValue)
-		if err != nil {
-			return nil, err
-		}
-		width = barcodeWidthDimension
-		height = barcodeHeightDimension
-	} else {
-		return nil, errors.New("unsupported code as input")
+func insertCode(kind codeKind, opts placeholderOpts, replaceValue string) (barcode.Barcode, error) {
+	// generated code from value has to be converted to an image to retrieve the bytes
+	// bytes are used to create image and the needed image reference by adding it to the document
+	code, err := kind.encode(replaceValue, opts)
+	if err != nil {
+		return nil, err
 	}
 
-	return barcode.Scale(code, width, height)
+	return barcode.Scale(code, kind.pixelW, kind.pixelH)
 }
 
 func trimSpaceAndToLower(str string) string {
@@ -358,12 +701,16 @@ func trimSpaceAndToLower(str string) string {
 	return str
 }
 
-// isQRCode returns true in case the string is prefixed by `qrcode`
-func isQRCode(str string) bool {
-	return strings.HasPrefix(trimSpaceAndToLower(str), "qrcode")
-}
+// lookupCodeKind resolves a placeholder (e.g. `barcode` or `aztec_serial`) to
+// the codeKind it is prefixed with. Used for the legacy grammar where the
+// whole placeholder doubles as the mapping key.
+func lookupCodeKind(str string) (codeKind, bool) {
+	normalized := trimSpaceAndToLower(str)
+	for name, kind := range codeKinds {
+		if strings.HasPrefix(normalized, name) {
+			return kind, true
+		}
+	}
 
-// isBarcode returns true in case the string is prefixed by `barcode`
-func isBarcode(str string) bool {
-	return strings.HasPrefix(trimSpaceAndToLower(str), "barcode")
+	return codeKind{}, false
 }
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/unidoc/unioffice/document"
+	"github.com/unidoc/unioffice/schema/soo/wml"
+)
+
+// TestCollectParagraphs_NestedTables checks that collectParagraphs recurses
+// into a table nested inside a table cell, not just the outer table's own
+// cells.
+func TestCollectParagraphs_NestedTables(t *testing.T) {
+	doc := document.New()
+	defer doc.Close()
+
+	outer := doc.AddTable()
+	outerCell := outer.AddRow().AddCell()
+	outerCell.AddParagraph().AddRun().AddText("outer")
+
+	inner := outerCell.AddTable()
+	innerCell := inner.AddRow().AddCell()
+	innerCell.AddParagraph().AddRun().AddText("inner")
+
+	paragraphs := collectParagraphs(doc)
+
+	var texts []string
+	for _, p := range paragraphs {
+		for _, r := range p.Runs() {
+			texts = append(texts, r.Text())
+		}
+	}
+
+	if !contains(texts, "outer") {
+		t.Errorf("collectParagraphs(doc) = %v, want it to include the outer cell's paragraph", texts)
+	}
+	if !contains(texts, "inner") {
+		t.Errorf("collectParagraphs(doc) = %v, want it to recurse into the table nested in the outer cell", texts)
+	}
+}
+
+// TestFillMappings_HeaderAndTableCell checks that fillMappings reaches
+// placeholders in a header and in a table cell, not just the document body.
+//
+// unioffice's public API only lets us enumerate existing
+// StructuredDocumentTags, not construct one in a fresh document, so that
+// container isn't exercised by a test here. It is walked through the same
+// collectParagraphs recursion proven correct above, so it gets the same
+// table-nesting coverage as the body, headers, footers and table cells.
+func TestFillMappings_HeaderAndTableCell(t *testing.T) {
+	doc := document.New()
+	defer doc.Close()
+
+	header := doc.AddHeader()
+	header.AddParagraph().AddRun().AddText("{barcode:code}")
+	doc.BodySection().SetHeader(header, wml.ST_HdrFtrDefault_Default)
+
+	cell := doc.AddTable().AddRow().AddCell()
+	cell.AddParagraph().AddRun().AddText("{barcode:code}")
+
+	mappings := map[string]string{"code": "12345"}
+	if err := fillMappings(doc, mappings); err != nil {
+		t.Fatalf("fillMappings() returned error: %v", err)
+	}
+
+	for _, header := range doc.Headers() {
+		for _, p := range collectParagraphs(header) {
+			for _, r := range p.Runs() {
+				if strings.Contains(r.Text(), "{") {
+					t.Errorf("header run text = %q, want the placeholder consumed", r.Text())
+				}
+			}
+		}
+	}
+
+	for _, p := range collectParagraphs(doc) {
+		for _, r := range p.Runs() {
+			if strings.Contains(r.Text(), "{") {
+				t.Errorf("body run text = %q, want the placeholder consumed", r.Text())
+			}
+		}
+	}
+}
+
+func contains(haystack []string, needle string) bool {
+	for _, s := range haystack {
+		if s == needle {
+			return true
+		}
+	}
+	return false
+}
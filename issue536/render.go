@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/unidoc/unioffice/document"
+	"github.com/unidoc/unioffice/document/convert"
+)
+
+// OutputPDF and OutputDocx are the formats Render knows how to produce.
+const (
+	OutputPDF  = "pdf"
+	OutputDocx = "docx"
+)
+
+// Render runs the mail-merge + barcode/QR pipeline against templateBytes and
+// returns the rendered document as either a PDF (outputFormat == OutputPDF,
+// the default) or a filled-in docx (outputFormat == OutputDocx). It is the
+// shared entry point for both the single-shot CLI flow and the HTTP server,
+// so every caller gets unique, cleaned-up temp files and can bound work via
+// ctx.
+func Render(ctx context.Context, templateBytes []byte, mappings map[string]string, outputFormat string) ([]byte, error) {
+	if outputFormat == "" {
+		outputFormat = OutputPDF
+	}
+	if outputFormat != OutputPDF && outputFormat != OutputDocx {
+		return nil, fmt.Errorf("unsupported output format %q, want %q or %q", outputFormat, OutputPDF, OutputDocx)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	templateFile, err := os.CreateTemp("", "unidoc-image-issue-template-*.docx")
+	if err != nil {
+		return nil, fmt.Errorf("create temp template file: %w", err)
+	}
+	defer os.Remove(templateFile.Name())
+	defer templateFile.Close()
+
+	if _, err := templateFile.Write(templateBytes); err != nil {
+		return nil, fmt.Errorf("write temp template file: %w", err)
+	}
+
+	doc, err := document.Open(templateFile.Name())
+	if err != nil {
+		return nil, fmt.Errorf("open template: %w", err)
+	}
+	defer doc.Close()
+
+	doc.MailMerge(mappings)
+
+	for _, field := range doc.FormFields() {
+		if field.Type() == document.FormFieldTypeCheckBox {
+			// name can be set in word via right click on the checkbox, and setting a value in "bookmark"
+			// value is either "true" or "false" for checkboxes
+			val, ok := mappings[field.Name()]
+			isChecked := ok && strings.ToLower(val) == "true"
+			field.SetChecked(isChecked)
+		}
+	}
+
+	if err := fillMappings(doc, mappings); err != nil {
+		return nil, fmt.Errorf("fill placeholders: %w", err)
+	}
+
+	// doc has to be copied so the eventually added images of barcodes are also exported to the PDF
+	renewedDoc, err := doc.Copy()
+	if err != nil {
+		return nil, fmt.Errorf("copy rendered document: %w", err)
+	}
+	defer renewedDoc.Close()
+
+	renderedFile, err := os.CreateTemp("", "unidoc-image-issue-rendered-*.docx")
+	if err != nil {
+		return nil, fmt.Errorf("create temp output file: %w", err)
+	}
+	defer os.Remove(renderedFile.Name())
+	defer renderedFile.Close()
+
+	if err := renewedDoc.SaveToFile(renderedFile.Name()); err != nil {
+		return nil, fmt.Errorf("save rendered document: %w", err)
+	}
+
+	if outputFormat == OutputDocx {
+		return os.ReadFile(renderedFile.Name())
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	completed, err := document.Open(renderedFile.Name())
+	if err != nil {
+		return nil, fmt.Errorf("reopen rendered document: %w", err)
+	}
+	defer completed.Close()
+
+	pdfFile, err := os.CreateTemp("", "unidoc-image-issue-output-*.pdf")
+	if err != nil {
+		return nil, fmt.Errorf("create temp pdf file: %w", err)
+	}
+	defer os.Remove(pdfFile.Name())
+	defer pdfFile.Close()
+
+	pdfDoc := convert.ConvertToPdf(completed)
+	if err := pdfDoc.WriteToFile(pdfFile.Name()); err != nil {
+		return nil, fmt.Errorf("write pdf: %w", err)
+	}
+
+	return os.ReadFile(pdfFile.Name())
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+)
+
+// jsonRenderRequest is the application/json counterpart to the multipart
+// form fields accepted by the /render endpoint: the template is inlined as
+// base64 instead of being a form file.
+type jsonRenderRequest struct {
+	Template string            `json:"template"`
+	Mappings map[string]string `json:"mappings"`
+	Output   string            `json:"output"`
+}
+
+// serve starts the HTTP server exposing POST /render and blocks until it
+// exits (e.g. on a listen error).
+func serve(addr string, maxBodyBytes int64) error {
+	if maxBodyBytes <= 0 {
+		return fmt.Errorf("max body bytes must be positive, got %d", maxBodyBytes)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/render", renderHandler(maxBodyBytes))
+
+	log.Printf("listening on %s", addr)
+	return http.ListenAndServe(addr, mux)
+}
+
+func renderHandler(maxBodyBytes int64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+
+		templateBytes, mappings, output, err := parseRenderRequest(r, maxBodyBytes)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := Render(r.Context(), templateBytes, mappings, output)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentTypeFor(output))
+		if _, err := w.Write(result); err != nil {
+			log.Printf("write render response: %v", err)
+		}
+	}
+}
+
+// parseRenderRequest reads either a multipart/form-data or an
+// application/json request body and extracts the template, mappings and
+// requested output format.
+func parseRenderRequest(r *http.Request, maxBodyBytes int64) (templateBytes []byte, mappings map[string]string, output string, err error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("invalid Content-Type: %w", err)
+	}
+
+	switch mediaType {
+	case "multipart/form-data":
+		if err := r.ParseMultipartForm(maxBodyBytes); err != nil {
+			return nil, nil, "", fmt.Errorf("parse multipart form: %w", err)
+		}
+
+		file, _, err := r.FormFile("template")
+		if err != nil {
+			return nil, nil, "", fmt.Errorf("read template form file: %w", err)
+		}
+		defer file.Close()
+
+		templateBytes, err = io.ReadAll(file)
+		if err != nil {
+			return nil, nil, "", fmt.Errorf("read template form file: %w", err)
+		}
+
+		mappings = make(map[string]string)
+		if raw := r.FormValue("mappings"); raw != "" {
+			if err := json.Unmarshal([]byte(raw), &mappings); err != nil {
+				return nil, nil, "", fmt.Errorf("parse mappings: %w", err)
+			}
+		}
+
+		return templateBytes, mappings, r.FormValue("output"), nil
+
+	case "application/json":
+		var req jsonRenderRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return nil, nil, "", fmt.Errorf("parse json body: %w", err)
+		}
+
+		templateBytes, err = base64.StdEncoding.DecodeString(req.Template)
+		if err != nil {
+			return nil, nil, "", fmt.Errorf("decode base64 template: %w", err)
+		}
+
+		return templateBytes, req.Mappings, req.Output, nil
+
+	default:
+		return nil, nil, "", fmt.Errorf("unsupported Content-Type %q, want multipart/form-data or application/json", mediaType)
+	}
+}
+
+func contentTypeFor(output string) string {
+	if output == OutputDocx {
+		return "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	}
+	return "application/pdf"
+}
@@ -5,16 +5,17 @@ import (
 	"os"
 
 	cfg "github.com/ardanlabs/conf/v3"
-	"github.com/unidoc/unioffice/common/license"
+	"github.com/pflege-de/unidoc-image-issue/internal/licensing"
 	"github.com/unidoc/unioffice/document"
 	"github.com/unidoc/unioffice/document/convert"
-	pdflicense "github.com/unidoc/unipdf/v3/common/license"
 )
 
 type config struct {
 	UniofficeLicenseKey   string `conf:"flag:license,env:LICENSE_KEY"`
 	UniofficeCustomerName string `conf:"flag:name,env:CUSTOMER_NAME"`
 	UniofficeApiKey       string `conf:"flag:key,env:API_KEY"`
+	UniofficeLicenseFile  string `conf:"flag:license-file,env:LICENSE_FILE"`
+	UniofficeLicenseDir   string `conf:"flag:license-dir,env:LICENSE_DIR"`
 }
 
 func main() {
@@ -30,35 +31,14 @@ func main() {
 		os.Exit(1)
 	}
 
-	if conf.UniofficeApiKey == "" && conf.UniofficeLicenseKey == "" {
-	}
-
-	// Register!
-	switch {
-	case conf.UniofficeApiKey != "":
-		if err := license.SetMeteredKey(conf.UniofficeApiKey); err != nil {
-			fmt.Println(err, "set unioffice api key")
-			os.Exit(1)
-		}
-		if err := pdflicense.SetMeteredKey(conf.UniofficeApiKey); err != nil {
-			fmt.Println(err, "set unioffice api key")
-			os.Exit(1)
-		}
-	case conf.UniofficeLicenseKey != "":
-		if conf.UniofficeCustomerName == "" {
-			fmt.Println("customer name required for license key")
-			os.Exit(1)
-		}
-		if err := license.SetLicenseKey(conf.UniofficeLicenseKey, conf.UniofficeCustomerName); err != nil {
-			fmt.Println(err, "set unioffice license key")
-			os.Exit(1)
-		}
-		if err := pdflicense.SetLicenseKey(conf.UniofficeLicenseKey, conf.UniofficeCustomerName); err != nil {
-			fmt.Println(err, "set unioffice license key")
-			os.Exit(1)
-		}
-	default:
-		fmt.Println("neither api or license key provided")
+	if err := licensing.Apply(licensing.Config{
+		ApiKey:       conf.UniofficeApiKey,
+		LicenseKey:   conf.UniofficeLicenseKey,
+		CustomerName: conf.UniofficeCustomerName,
+		LicenseFile:  conf.UniofficeLicenseFile,
+		LicenseDir:   conf.UniofficeLicenseDir,
+	}); err != nil {
+		fmt.Println(err)
 		os.Exit(1)
 	}
 